@@ -0,0 +1,116 @@
+package rao
+
+import (
+	"context"
+	"fmt"
+	"os"
+	gemini "rao/utils"
+	"strings"
+)
+
+// LLMBackend is implemented by anything that can answer a GenerateObject-style
+// call for a family of models, so a single orchestration can fan out across
+// multiple providers instead of being hard-coded to Gemini.
+type LLMBackend interface {
+	GenerateObject(ctx context.Context, model, prompt, system string, structured bool) (map[string]interface{}, error)
+}
+
+// StreamingBackend is implemented by backends that can additionally stream
+// their response token-by-token. runAgentsParallelStreaming uses it when
+// available and falls back to a single GenerateObject call otherwise.
+type StreamingBackend interface {
+	LLMBackend
+	// GenerateObjectStream streams the response one chunk at a time on the
+	// first channel. Once the stream ends, the backend sends its usage
+	// metadata (shaped like GenerateObject's "usage" value, e.g.
+	// {"total_tokens": N}) on the third channel exactly once and closes it;
+	// a backend that can't report usage for a stream closes it without a
+	// value, just like GenerateObject omitting "usage" from its response.
+	GenerateObjectStream(ctx context.Context, model, prompt, system string, structured bool) (<-chan string, <-chan error, <-chan map[string]interface{})
+}
+
+// BackendRegistry resolves an Agent's Model field to the LLMBackend that
+// should serve it, keyed by model name prefix (e.g. "gemini-", "gpt-",
+// "claude-", "llama-"). A model with no matching prefix is served by the
+// registry's fallback backend.
+type BackendRegistry struct {
+	backends map[string]LLMBackend
+	fallback LLMBackend
+}
+
+// NewBackendRegistry creates an empty registry. fallback serves any model
+// whose prefix isn't explicitly registered; pass nil to require every model
+// to be registered explicitly.
+func NewBackendRegistry(fallback LLMBackend) *BackendRegistry {
+	return &BackendRegistry{backends: make(map[string]LLMBackend), fallback: fallback}
+}
+
+// Register associates a model name prefix with the backend that should serve it.
+func (r *BackendRegistry) Register(prefix string, backend LLMBackend) {
+	r.backends[strings.ToLower(prefix)] = backend
+}
+
+// Resolve returns the backend registered for model's prefix, falling back to
+// the registry's default backend when no prefix matches.
+func (r *BackendRegistry) Resolve(model string) (LLMBackend, error) {
+	lower := strings.ToLower(model)
+	for prefix, backend := range r.backends {
+		if strings.HasPrefix(lower, prefix) {
+			return backend, nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("no backend registered for model: %s", model)
+}
+
+// DefaultBackendRegistry builds a registry from environment variables, so
+// operators can opt extra providers in without code changes. Gemini is
+// always registered as the fallback, since it also serves the master agent
+// and synthesis calls:
+//   - OPENAI_API_KEY and/or OPENAI_BASE_URL register "gpt-" against an
+//     OpenAI-compatible backend.
+//   - LLAMA_BASE_URL registers "llama-" against a local OpenAI-compatible
+//     inference server.
+//   - ANTHROPIC_API_KEY registers "claude-" against Anthropic's Messages API.
+func DefaultBackendRegistry(client *gemini.GeminiClient) *BackendRegistry {
+	registry := NewBackendRegistry(client)
+
+	if baseURL, apiKey := os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY"); baseURL != "" || apiKey != "" {
+		if backend, err := NewOpenAICompatibleBackend(baseURL, apiKey); err == nil {
+			registry.Register("gpt-", backend)
+		}
+	}
+
+	if baseURL := os.Getenv("LLAMA_BASE_URL"); baseURL != "" {
+		if backend, err := NewOpenAICompatibleBackend(baseURL, os.Getenv("LLAMA_API_KEY")); err == nil {
+			registry.Register("llama-", backend)
+		}
+	}
+
+	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
+		registry.Register("claude-", NewAnthropicBackend(apiKey))
+	}
+
+	return registry
+}
+
+// tokensConsumed extracts a usage.total_tokens-shaped value from a backend's
+// response, if present, so AgentResult.TokensConsumed can be populated
+// regardless of which provider served the agent.
+func tokensConsumed(resp map[string]interface{}) int {
+	usage, ok := resp["usage"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+
+	switch v := usage["total_tokens"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
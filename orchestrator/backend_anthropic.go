@@ -0,0 +1,88 @@
+package rao
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AnthropicBackend serves claude-* models via Anthropic's Messages API.
+type AnthropicBackend struct {
+	apiKey string
+	http   *http.Client
+}
+
+func NewAnthropicBackend(apiKey string) *AnthropicBackend {
+	return &AnthropicBackend{apiKey: apiKey, http: &http.Client{}}
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (b *AnthropicBackend) GenerateObject(ctx context.Context, model, prompt, system string, structured bool) (map[string]interface{}, error) {
+	reqBody := anthropicRequest{
+		Model:     model,
+		MaxTokens: 4096,
+		System:    system,
+		Messages:  []anthropicMessage{{Role: "user", Content: prompt}},
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", b.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("anthropic backend returned status %d", resp.StatusCode)
+	}
+
+	var parsed anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode anthropic response: %v", err)
+	}
+	if len(parsed.Content) == 0 {
+		return nil, fmt.Errorf("anthropic backend returned no content")
+	}
+
+	return map[string]interface{}{
+		"response": parsed.Content[0].Text,
+		"usage": map[string]interface{}{
+			"total_tokens": parsed.Usage.InputTokens + parsed.Usage.OutputTokens,
+		},
+	}, nil
+}
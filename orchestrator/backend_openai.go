@@ -0,0 +1,189 @@
+package rao
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAICompatibleBackend serves any model exposed through an OpenAI-style
+// /v1/chat/completions endpoint, whether that's OpenAI itself (gpt-* models)
+// or a local inference server speaking the same API (llama-* models, see
+// DefaultBackendRegistry).
+type OpenAICompatibleBackend struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func NewOpenAICompatibleBackend(baseURL, apiKey string) (*OpenAICompatibleBackend, error) {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+
+	return &OpenAICompatibleBackend{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{},
+	}, nil
+}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIChatMessage  `json:"messages"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// openAIStreamOptions asks the endpoint to emit a final SSE chunk carrying
+// usage totals for the whole call, the way it normally only does for
+// non-streaming responses.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (b *OpenAICompatibleBackend) request(ctx context.Context, reqBody openAIChatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("openai-compatible backend returned status %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+func (b *OpenAICompatibleBackend) messages(prompt, system string) []openAIChatMessage {
+	var messages []openAIChatMessage
+	if system != "" {
+		messages = append(messages, openAIChatMessage{Role: "system", Content: system})
+	}
+	return append(messages, openAIChatMessage{Role: "user", Content: prompt})
+}
+
+func (b *OpenAICompatibleBackend) GenerateObject(ctx context.Context, model, prompt, system string, structured bool) (map[string]interface{}, error) {
+	resp, err := b.request(ctx, openAIChatRequest{Model: model, Messages: b.messages(prompt, system)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode openai-compatible response: %v", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, fmt.Errorf("openai-compatible backend returned no choices")
+	}
+
+	return map[string]interface{}{
+		"response": parsed.Choices[0].Message.Content,
+		"usage": map[string]interface{}{
+			"total_tokens": parsed.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// GenerateObjectStream satisfies StreamingBackend by reading the endpoint's
+// server-sent-events stream and forwarding each delta's content as a chunk.
+// It asks for stream_options.include_usage so the final SSE chunk carries
+// usage totals, which are reported on the usage channel once the stream
+// ends.
+func (b *OpenAICompatibleBackend) GenerateObjectStream(ctx context.Context, model, prompt, system string, structured bool) (<-chan string, <-chan error, <-chan map[string]interface{}) {
+	chunks := make(chan string)
+	errs := make(chan error, 1)
+	usage := make(chan map[string]interface{}, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+		defer close(usage)
+
+		resp, err := b.request(ctx, openAIChatRequest{
+			Model:         model,
+			Messages:      b.messages(prompt, system),
+			Stream:        true,
+			StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+		})
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				break
+			}
+
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+				Usage *struct {
+					TotalTokens int `json:"total_tokens"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				continue
+			}
+			if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+				chunks <- event.Choices[0].Delta.Content
+			}
+			if event.Usage != nil {
+				usage <- map[string]interface{}{"total_tokens": event.Usage.TotalTokens}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			errs <- err
+		}
+	}()
+
+	return chunks, errs, usage
+}
@@ -0,0 +1,263 @@
+package rao
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// CycleError is returned when the agents produced by the master response
+// form a dependency cycle and cannot be scheduled.
+type CycleError struct {
+	Nodes []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected among agents: %s", strings.Join(e.Nodes, ", "))
+}
+
+// UnknownDependencyError is returned when an agent's depends_on references an
+// id that doesn't match any agent in the master response.
+type UnknownDependencyError struct {
+	Agent      string
+	Dependency string
+}
+
+func (e *UnknownDependencyError) Error() string {
+	return fmt.Sprintf("agent %q depends on unknown agent %q", e.Agent, e.Dependency)
+}
+
+// agentID returns the identifier an agent is addressed by in depends_on and
+// template placeholders. assignAgentIDs guarantees this is always set by the
+// time agents reach buildDAG's bookkeeping or any code downstream of it.
+func agentID(agent Agent) string {
+	return agent.ID
+}
+
+// assignAgentIDs returns a copy of agents with a unique ID filled in for
+// every agent that didn't set one explicitly. Agents are commonly emitted
+// by the master with a shared Type and no id (e.g. N independent
+// "researcher" agents fanned out in parallel), so falling back to Type alone
+// would collapse them onto the same map key; an index suffix keeps them
+// distinct while leaving explicit ids (used to wire up depends_on) untouched.
+func assignAgentIDs(agents []Agent) []Agent {
+	assigned := make([]Agent, len(agents))
+	for i, agent := range agents {
+		if agent.ID == "" {
+			agent.ID = fmt.Sprintf("%s#%d", agent.Type, i)
+		}
+		assigned[i] = agent
+	}
+	return assigned
+}
+
+// buildDAG groups agents into levels using Kahn's algorithm: level 0 holds
+// every agent with no dependencies, level 1 holds agents whose dependencies
+// are all in level 0, and so on. Agents within a level have no dependency
+// relationship between them and can run concurrently.
+func buildDAG(agents []Agent) ([][]Agent, error) {
+	agents = assignAgentIDs(agents)
+
+	byID := make(map[string]Agent, len(agents))
+	order := make([]string, 0, len(agents))
+	for _, agent := range agents {
+		id := agentID(agent)
+		byID[id] = agent
+		order = append(order, id)
+	}
+
+	indegree := make(map[string]int, len(agents))
+	dependents := make(map[string][]string, len(agents))
+	for _, id := range order {
+		indegree[id] = 0
+	}
+
+	for _, agent := range agents {
+		id := agentID(agent)
+		for _, dep := range agent.DependsOn {
+			if _, ok := byID[dep]; !ok {
+				return nil, &UnknownDependencyError{Agent: id, Dependency: dep}
+			}
+			indegree[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	var ready []string
+	for _, id := range order {
+		if indegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	processed := make(map[string]bool, len(order))
+	var levels [][]Agent
+
+	for len(ready) > 0 {
+		level := make([]Agent, 0, len(ready))
+		for _, id := range ready {
+			level = append(level, byID[id])
+			processed[id] = true
+		}
+		levels = append(levels, level)
+
+		var next []string
+		for _, id := range ready {
+			for _, dependent := range dependents[id] {
+				indegree[dependent]--
+				if indegree[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		ready = next
+	}
+
+	if len(processed) != len(order) {
+		var cyclic []string
+		for _, id := range order {
+			if !processed[id] {
+				cyclic = append(cyclic, id)
+			}
+		}
+		return nil, &CycleError{Nodes: cyclic}
+	}
+
+	return levels, nil
+}
+
+// interpolateDeps renders {{.Deps.<id>.response}}-style placeholders in an
+// agent's prompt and system text using the results of its already-completed
+// dependencies. Text without any placeholders is returned unchanged.
+func interpolateDeps(text string, deps map[string]map[string]interface{}) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	tmpl, err := template.New("agent").Parse(text)
+	if err != nil {
+		log.Printf("Error parsing agent dependency template: %v", err)
+		return text
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Deps map[string]map[string]interface{}
+	}{Deps: deps}
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error executing agent dependency template: %v", err)
+		return text
+	}
+
+	return buf.String()
+}
+
+func resolveAgent(agent Agent, deps map[string]map[string]interface{}) Agent {
+	agent.Prompt = interpolateDeps(agent.Prompt, deps)
+	agent.System = interpolateDeps(agent.System, deps)
+	return agent
+}
+
+// runAgentsDAG schedules agents level-by-level according to their
+// depends_on edges, running each level concurrently via the existing
+// fan-out executors and feeding completed results into downstream agents'
+// templated prompts/system text. updateChan may be nil for the
+// non-streaming path.
+func (o *Orchestrator) runAgentsDAG(ctx context.Context, agents []Agent, updateChan chan<- *StatusUpdate) ([]*AgentResult, error) {
+	levels, err := buildDAG(agents)
+	if err != nil {
+		return nil, err
+	}
+
+	if updateChan != nil {
+		updateChan <- &StatusUpdate{
+			Type:    "dag_built",
+			Message: fmt.Sprintf("Built agent dependency graph with %d level(s)", len(levels)),
+			Data: map[string]interface{}{
+				"levels": dagLevelIDs(levels),
+			},
+			Time: time.Now(),
+		}
+
+		waitingLevels := levels
+		if len(waitingLevels) > 0 {
+			waitingLevels = waitingLevels[1:]
+		}
+		for _, level := range waitingLevels {
+			for _, agent := range level {
+				updateChan <- &StatusUpdate{
+					Type:    "agent_waiting",
+					Message: fmt.Sprintf("Agent %s waiting on dependencies: %s", agentID(agent), strings.Join(agent.DependsOn, ", ")),
+					Data: map[string]interface{}{
+						"agent_id":   agentID(agent),
+						"depends_on": agent.DependsOn,
+					},
+					Time: time.Now(),
+				}
+			}
+		}
+	}
+
+	deps := make(map[string]map[string]interface{})
+	var allResults []*AgentResult
+
+	for levelIdx, level := range levels {
+		resolved := make([]Agent, 0, len(level))
+		for _, agent := range level {
+			resolvedAgent := resolveAgent(agent, deps)
+			resolved = append(resolved, resolvedAgent)
+
+			if updateChan != nil && levelIdx > 0 {
+				updateChan <- &StatusUpdate{
+					Type:    "agent_unblocked",
+					Message: fmt.Sprintf("Agent %s unblocked, all dependencies resolved", agentID(agent)),
+					Data: map[string]interface{}{
+						"agent_id":   agentID(agent),
+						"depends_on": agent.DependsOn,
+					},
+					Time: time.Now(),
+				}
+			}
+		}
+
+		var levelResults []*AgentResult
+		if updateChan != nil {
+			levelResults = o.runAgentsParallelStreaming(ctx, resolved, updateChan)
+		} else {
+			levelResults = o.runAgentsParallel(ctx, resolved)
+		}
+
+		// levelResults comes back in goroutine-completion order, not the
+		// input order of level/resolved, so match results to agents by ID
+		// rather than position.
+		for _, result := range levelResults {
+			deps[result.ID] = map[string]interface{}{
+				"response": result.Response,
+				"type":     result.Type,
+				"model":    result.Model,
+				"error":    result.ErrorMsg,
+			}
+		}
+
+		allResults = append(allResults, levelResults...)
+	}
+
+	return allResults, nil
+}
+
+func dagLevelIDs(levels [][]Agent) [][]string {
+	ids := make([][]string, len(levels))
+	for i, level := range levels {
+		levelIDs := make([]string, len(level))
+		for j, agent := range level {
+			levelIDs[j] = agentID(agent)
+		}
+		ids[i] = levelIDs
+	}
+	return ids
+}
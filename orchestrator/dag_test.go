@@ -0,0 +1,43 @@
+package rao
+
+import "testing"
+
+func TestBuildDAGAssignsDistinctIDsForSameTypeAgents(t *testing.T) {
+	agents := []Agent{
+		{Type: "researcher"},
+		{Type: "researcher"},
+	}
+
+	levels, err := buildDAG(agents)
+	if err != nil {
+		t.Fatalf("buildDAG returned an error for independent same-type agents: %v", err)
+	}
+
+	if len(levels) != 1 || len(levels[0]) != 2 {
+		t.Fatalf("expected a single level with 2 agents, got %d level(s): %v", len(levels), dagLevelIDs(levels))
+	}
+
+	if levels[0][0].ID == levels[0][1].ID {
+		t.Fatalf("expected distinct ids for same-type agents, both got %q", levels[0][0].ID)
+	}
+}
+
+func TestBuildDAGOrdersByDependsOn(t *testing.T) {
+	agents := []Agent{
+		{Type: "researcher", ID: "r1"},
+		{Type: "writer", ID: "w1", DependsOn: []string{"r1"}},
+	}
+
+	levels, err := buildDAG(agents)
+	if err != nil {
+		t.Fatalf("buildDAG returned an unexpected error: %v", err)
+	}
+
+	if len(levels) != 2 || len(levels[0]) != 1 || len(levels[1]) != 1 {
+		t.Fatalf("expected two single-agent levels, got %v", dagLevelIDs(levels))
+	}
+
+	if levels[0][0].ID != "r1" || levels[1][0].ID != "w1" {
+		t.Fatalf("expected r1 before w1, got %v", dagLevelIDs(levels))
+	}
+}
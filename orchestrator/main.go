@@ -1,6 +1,8 @@
 package rao
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -8,37 +10,74 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 type Agent struct {
-	Type    string `json:"type"`
-	Usecase string `json:"usecase"`
-	System  string `json:"system"`
-	Prompt  string `json:"prompt"`
-	Model   string `json:"model"`
+	Type      string   `json:"type"`
+	Usecase   string   `json:"usecase"`
+	System    string   `json:"system"`
+	Prompt    string   `json:"prompt"`
+	Model     string   `json:"model"`
+	ID        string   `json:"id,omitempty"`
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+// parseAgent builds an Agent from the master response's decoded agent map.
+// ID and DependsOn are optional; agents without them are treated as
+// independent nodes with no unresolved dependencies.
+func parseAgent(agentMap map[string]interface{}) Agent {
+	agent := Agent{
+		Type:    agentMap["type"].(string),
+		Usecase: agentMap["usecase"].(string),
+		System:  agentMap["system"].(string),
+		Prompt:  agentMap["prompt"].(string),
+		Model:   agentMap["model"].(string),
+	}
+
+	if id, ok := agentMap["id"].(string); ok {
+		agent.ID = id
+	}
+
+	if dependsOn, ok := agentMap["depends_on"].([]interface{}); ok {
+		for _, dep := range dependsOn {
+			if depStr, ok := dep.(string); ok {
+				agent.DependsOn = append(agent.DependsOn, depStr)
+			}
+		}
+	}
+
+	return agent
 }
 
 type AgentResult struct {
-	Type       string        `json:"type"`
-	Query      string        `json:"query"`
-	Response   string        `json:"response"`
-	Error      error         `json:"-"`
-	ErrorMsg   string        `json:"error,omitempty"`
-	Model      string        `json:"model"`
-	Duration   time.Duration `json:"duration"`
-	StartTime  time.Time     `json:"start_time"`
-	FinishTime time.Time     `json:"finish_time"`
+	ID             string        `json:"id,omitempty"`
+	Type           string        `json:"type"`
+	Query          string        `json:"query"`
+	Response       string        `json:"response"`
+	Error          error         `json:"-"`
+	ErrorMsg       string        `json:"error,omitempty"`
+	Model          string        `json:"model"`
+	Duration       time.Duration `json:"duration"`
+	StartTime      time.Time     `json:"start_time"`
+	FinishTime     time.Time     `json:"finish_time"`
+	Attempts       int           `json:"attempts"`
+	RetryHistory   []error       `json:"-"`
+	RetryErrors    []string      `json:"retry_history,omitempty"`
+	TokensConsumed int           `json:"tokens_consumed,omitempty"`
 }
 
 type ProcessSummary struct {
-	TotalAgents     int           `json:"total_agents"`
-	SuccessfulRuns  int           `json:"successful_runs"`
-	FailedRuns      int           `json:"failed_runs"`
-	TotalDuration   time.Duration `json:"total_duration"`
-	StartTime       time.Time     `json:"start_time"`
-	FinishTime      time.Time     `json:"finish_time"`
-	MasterAgentType string        `json:"master_agent_type"`
-	FinalAgentType  string        `json:"final_agent_type"`
+	TotalAgents         int           `json:"total_agents"`
+	SuccessfulRuns      int           `json:"successful_runs"`
+	FailedRuns          int           `json:"failed_runs"`
+	TotalDuration       time.Duration `json:"total_duration"`
+	StartTime           time.Time     `json:"start_time"`
+	FinishTime          time.Time     `json:"finish_time"`
+	MasterAgentType     string        `json:"master_agent_type"`
+	FinalAgentType      string        `json:"final_agent_type"`
+	TotalTokensConsumed int           `json:"total_tokens_consumed,omitempty"`
 }
 
 type OrchestrationResult struct {
@@ -56,19 +95,113 @@ type StatusUpdate struct {
 	Time     time.Time   `json:"timestamp"`
 }
 
+// OrchestratorConfig controls the timeouts applied to an orchestration run.
+// Zero timeouts, and a Retry whose MaxAttempts is zero, are replaced with the
+// defaults from DefaultOrchestratorConfig; nil Synthesizer and Backends fall
+// back as described on those fields.
+type OrchestratorConfig struct {
+	// AgentTimeout bounds a single agent's call to the backing model.
+	AgentTimeout time.Duration
+	// OrchestrationTimeout bounds the entire run, from master agent through final synthesis.
+	OrchestrationTimeout time.Duration
+	// Synthesizer performs the final synthesis step. Defaults to LearnLM synthesis when nil.
+	Synthesizer Synthesizer
+	// Retry controls backoff and attempt limits for agent calls.
+	Retry RetryConfig
+	// Backends resolves an Agent's Model field to the provider that should
+	// serve it. Defaults to DefaultBackendRegistry (Gemini, plus whichever
+	// other providers are configured via environment variables) when nil.
+	Backends *BackendRegistry
+}
+
+// DefaultOrchestratorConfig returns the timeouts used when an Orchestrator is
+// created without an explicit config.
+func DefaultOrchestratorConfig() OrchestratorConfig {
+	return OrchestratorConfig{
+		AgentTimeout:         90 * time.Second,
+		OrchestrationTimeout: 5 * time.Minute,
+		Retry:                DefaultRetryConfig(),
+	}
+}
+
 type Orchestrator struct {
-	client *gemini.GeminiClient
+	client      *gemini.GeminiClient
+	config      OrchestratorConfig
+	synthesizer Synthesizer
+	backends    *BackendRegistry
+	callGroup   singleflight.Group
 }
 
 func NewOrchestrator() (*Orchestrator, error) {
+	return NewOrchestratorWithConfig(DefaultOrchestratorConfig())
+}
+
+// NewOrchestratorWithConfig creates an Orchestrator with explicit agent and
+// orchestration timeouts, and an optional Synthesizer. If config.Synthesizer
+// is nil, the orchestrator falls back to LearnLM synthesis. If
+// config.Backends is nil, it falls back to DefaultBackendRegistry.
+func NewOrchestratorWithConfig(config OrchestratorConfig) (*Orchestrator, error) {
+	if config.AgentTimeout <= 0 {
+		config.AgentTimeout = DefaultOrchestratorConfig().AgentTimeout
+	}
+	if config.OrchestrationTimeout <= 0 {
+		config.OrchestrationTimeout = DefaultOrchestratorConfig().OrchestrationTimeout
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry = DefaultRetryConfig()
+	}
+
+	client, err := gemini.NewGeminiClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %v", err)
+	}
+
+	synthesizer := config.Synthesizer
+	if synthesizer == nil {
+		synthesizer = NewLearnLMSynthesizer(client)
+	}
+
+	backends := config.Backends
+	if backends == nil {
+		backends = DefaultBackendRegistry(client)
+	}
+
+	return &Orchestrator{client: client, config: config, synthesizer: synthesizer, backends: backends}, nil
+}
+
+// NewOrchestratorWithSynthesis creates an Orchestrator using the default
+// timeouts, backends and the named synthesis strategy (see NewSynthesizer).
+func NewOrchestratorWithSynthesis(synthesisName string) (*Orchestrator, error) {
 	client, err := gemini.NewGeminiClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %v", err)
 	}
-	return &Orchestrator{client: client}, nil
+
+	synthesizer, err := NewSynthesizer(synthesisName, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Orchestrator{
+		client:      client,
+		config:      DefaultOrchestratorConfig(),
+		synthesizer: synthesizer,
+		backends:    DefaultBackendRegistry(client),
+	}, nil
 }
 
-func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt string, masterModelType string, updateChan chan<- *StatusUpdate) (*OrchestrationResult, error) {
+// RunAgentsStreaming runs the orchestration and emits StatusUpdates as it progresses.
+// The provided ctx bounds the whole run; cancelling it stops the master call,
+// any in-flight agents and the final synthesis. Since a resumable session
+// (see SessionStore) is meant to keep running and buffering updates across a
+// client's disconnect/reconnect, callers backing updateChan with a session
+// should only cancel ctx on session close, not on an individual WebSocket
+// disconnect - in that setup the run is instead bounded by
+// o.config.OrchestrationTimeout.
+func (o *Orchestrator) RunAgentsStreaming(ctx context.Context, masterPrompt string, systemPrompt string, masterModelType string, updateChan chan<- *StatusUpdate) (*OrchestrationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.config.OrchestrationTimeout)
+	defer cancel()
+
 	processStart := time.Now()
 	result := &OrchestrationResult{
 		MasterPrompt: masterPrompt,
@@ -87,7 +220,7 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 		Time: time.Now(),
 	}
 
-	masterResponse, err := o.client.GenerateObject(masterModelType, masterPrompt, systemPrompt, true)
+	masterResponse, err := o.client.GenerateObject(ctx, masterModelType, masterPrompt, systemPrompt, true)
 	if err != nil {
 		updateChan <- &StatusUpdate{
 			Type:    "error",
@@ -132,14 +265,7 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 			return nil, fmt.Errorf("invalid agent format")
 		}
 
-		agent := Agent{
-			Type:    agentMap["type"].(string),
-			Usecase: agentMap["usecase"].(string),
-			System:  agentMap["system"].(string),
-			Prompt:  agentMap["prompt"].(string),
-			Model:   agentMap["model"].(string),
-		}
-		agents = append(agents, agent)
+		agents = append(agents, parseAgent(agentMap))
 	}
 
 	updateChan <- &StatusUpdate{
@@ -149,7 +275,15 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 		Time:    time.Now(),
 	}
 
-	agentResults := o.runAgentsParallelStreaming(agents, updateChan)
+	agentResults, err := o.runAgentsDAG(ctx, agents, updateChan)
+	if err != nil {
+		updateChan <- &StatusUpdate{
+			Type:    "error",
+			Message: fmt.Sprintf("Failed to build agent dependency graph: %v", err),
+			Time:    time.Now(),
+		}
+		return nil, fmt.Errorf("failed to build agent dependency graph: %v", err)
+	}
 	result.AgentResults = agentResults
 
 	result.Process.TotalAgents = len(agents)
@@ -162,6 +296,7 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 		} else {
 			result.Process.SuccessfulRuns++
 		}
+		result.Process.TotalTokensConsumed += ar.TokensConsumed
 	}
 
 	updateChan <- &StatusUpdate{
@@ -178,14 +313,26 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 
 	updateChan <- &StatusUpdate{
 		Type:    "final_processing_started",
-		Message: "Starting final unbiased synthesis with LearnLM",
+		Message: fmt.Sprintf("Starting final synthesis with %s", o.synthesizer.Name()),
 		Time:    time.Now(),
 	}
 
-	finalPrompt := o.buildFinalLearnLMPrompt(masterPrompt, agentResults)
-	finalSystemPrompt := `You are LearnLM, an unbiased research and synthesis AI. Your task is to analyze all provided agent responses and create a comprehensive, unbiased final output that integrates all perspectives. Do not favor any specific agent or perspective. Present a balanced view that considers all input equally. Focus on factual information and clearly distinguish between consensus views and areas of disagreement. Do not add any personal opinions or biases. Your goal is to provide the most objective and comprehensive synthesis possible.`
-
-	finalResponse, err := o.client.GenerateObject(string(gemini.LearnLM), finalPrompt, finalSystemPrompt, false)
+	var finalResponse map[string]interface{}
+	if streaming, ok := o.synthesizer.(StreamingSynthesizer); ok {
+		finalResponse, err = streaming.SynthesizeStream(ctx, masterPrompt, agentResults, func(seq int, token string) {
+			updateChan <- &StatusUpdate{
+				Type:    "final_token",
+				Message: "Final synthesis token",
+				Data: map[string]interface{}{
+					"seq":   seq,
+					"token": token,
+				},
+				Time: time.Now(),
+			}
+		})
+	} else {
+		finalResponse, err = o.synthesizer.Synthesize(ctx, masterPrompt, agentResults)
+	}
 
 	if err != nil {
 		updateChan <- &StatusUpdate{
@@ -194,33 +341,19 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 			Time:    time.Now(),
 		}
 
-		allResponses := make(map[string]interface{})
-		allResponses["type"] = "Combined Agent Responses (Fallback)"
-		allResponses["query"] = masterPrompt
-
-		agentResponsesMap := make(map[string]string)
-		for _, ar := range agentResults {
-			if ar.Error == nil {
-				agentResponsesMap[ar.Type] = ar.Response
-			}
-		}
-
-		allResponses["response"] = "All agent responses provided without merging or filtering (fallback due to synthesis error)."
-		allResponses["agent_responses"] = agentResponsesMap
-
-		result.FinalResponse = allResponses
-		result.Process.FinalAgentType = "Direct Agent Response Collection (Fallback)"
+		result.FinalResponse = o.synthesizer.Fallback(masterPrompt, agentResults)
+		result.Process.FinalAgentType = fmt.Sprintf("%s (Fallback)", o.synthesizer.Name())
 	} else {
 		updateChan <- &StatusUpdate{
 			Type:    "final_processing_completed",
-			Message: "Completed final unbiased synthesis with LearnLM",
+			Message: fmt.Sprintf("Completed final synthesis with %s", o.synthesizer.Name()),
 			Data:    finalResponse,
 			Time:    time.Now(),
 		}
 
 		finalResponse["agent_responses_raw"] = o.collectRawAgentResponses(agentResults)
 		result.FinalResponse = finalResponse
-		result.Process.FinalAgentType = "LearnLM Unbiased Synthesis"
+		result.Process.FinalAgentType = o.synthesizer.Name()
 	}
 
 	result.Process.FinishTime = time.Now()
@@ -238,7 +371,13 @@ func (o *Orchestrator) RunAgentsStreaming(masterPrompt string, systemPrompt stri
 	return result, nil
 }
 
-func (o *Orchestrator) RunAgents(masterPrompt string, systemPrompt string, masterModelType string) (*OrchestrationResult, error) {
+// RunAgents runs the orchestration to completion and returns the final result.
+// The provided ctx bounds the whole run; cancelling it stops the master call,
+// any in-flight agents and the final synthesis.
+func (o *Orchestrator) RunAgents(ctx context.Context, masterPrompt string, systemPrompt string, masterModelType string) (*OrchestrationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.config.OrchestrationTimeout)
+	defer cancel()
+
 	processStart := time.Now()
 	result := &OrchestrationResult{
 		MasterPrompt: masterPrompt,
@@ -247,7 +386,7 @@ func (o *Orchestrator) RunAgents(masterPrompt string, systemPrompt string, maste
 		},
 	}
 
-	masterResponse, err := o.client.GenerateObject(masterModelType, masterPrompt, systemPrompt, true)
+	masterResponse, err := o.client.GenerateObject(ctx, masterModelType, masterPrompt, systemPrompt, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate master response: %v", err)
 	}
@@ -270,17 +409,13 @@ func (o *Orchestrator) RunAgents(masterPrompt string, systemPrompt string, maste
 			return nil, fmt.Errorf("invalid agent format")
 		}
 
-		agent := Agent{
-			Type:    agentMap["type"].(string),
-			Usecase: agentMap["usecase"].(string),
-			System:  agentMap["system"].(string),
-			Prompt:  agentMap["prompt"].(string),
-			Model:   agentMap["model"].(string),
-		}
-		agents = append(agents, agent)
+		agents = append(agents, parseAgent(agentMap))
 	}
 
-	agentResults := o.runAgentsParallel(agents)
+	agentResults, err := o.runAgentsDAG(ctx, agents, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build agent dependency graph: %v", err)
+	}
 	result.AgentResults = agentResults
 
 	result.Process.TotalAgents = len(agents)
@@ -293,35 +428,18 @@ func (o *Orchestrator) RunAgents(masterPrompt string, systemPrompt string, maste
 		} else {
 			result.Process.SuccessfulRuns++
 		}
+		result.Process.TotalTokensConsumed += ar.TokensConsumed
 	}
 
-	finalPrompt := o.buildFinalLearnLMPrompt(masterPrompt, agentResults)
-	finalSystemPrompt := `You are LearnLM, an unbiased research and synthesis AI. Your task is to analyze all provided agent responses and create a comprehensive, unbiased final output that integrates all perspectives. Do not favor any specific agent or perspective. Present a balanced view that considers all input equally. Focus on factual information and clearly distinguish between consensus views and areas of disagreement. Do not add any personal opinions or biases. Your goal is to provide the most objective and comprehensive synthesis possible.`
-
-	finalResponse, err := o.client.GenerateObject(string(gemini.LearnLM), finalPrompt, finalSystemPrompt, false)
+	finalResponse, err := o.synthesizer.Synthesize(ctx, masterPrompt, agentResults)
 
 	if err != nil {
-
-		allResponses := make(map[string]interface{})
-		allResponses["type"] = "Combined Agent Responses (Fallback)"
-		allResponses["query"] = masterPrompt
-
-		agentResponsesMap := make(map[string]string)
-		for _, ar := range agentResults {
-			if ar.Error == nil {
-				agentResponsesMap[ar.Type] = ar.Response
-			}
-		}
-
-		allResponses["response"] = "All agent responses provided without merging or filtering (fallback due to synthesis error)."
-		allResponses["agent_responses"] = agentResponsesMap
-
-		result.FinalResponse = allResponses
-		result.Process.FinalAgentType = "Direct Agent Response Collection (Fallback)"
+		result.FinalResponse = o.synthesizer.Fallback(masterPrompt, agentResults)
+		result.Process.FinalAgentType = fmt.Sprintf("%s (Fallback)", o.synthesizer.Name())
 	} else {
 		finalResponse["agent_responses_raw"] = o.collectRawAgentResponses(agentResults)
 		result.FinalResponse = finalResponse
-		result.Process.FinalAgentType = "LearnLM Unbiased Synthesis"
+		result.Process.FinalAgentType = o.synthesizer.Name()
 	}
 
 	result.Process.FinishTime = time.Now()
@@ -340,7 +458,7 @@ func (o *Orchestrator) collectRawAgentResponses(agentResults []*AgentResult) map
 	return agentResponsesMap
 }
 
-func (o *Orchestrator) runAgentsParallel(agents []Agent) []*AgentResult {
+func (o *Orchestrator) runAgentsParallel(ctx context.Context, agents []Agent) []*AgentResult {
 	var wg sync.WaitGroup
 	resultChan := make(chan *AgentResult, len(agents))
 
@@ -353,12 +471,16 @@ func (o *Orchestrator) runAgentsParallel(agents []Agent) []*AgentResult {
 			defer wg.Done()
 
 			result := &AgentResult{
+				ID:        agentCopy.ID,
 				Type:      agentCopy.Type,
 				Query:     agentCopy.Prompt,
 				Model:     agentCopy.Model,
 				StartTime: time.Now(),
 			}
 
+			agentCtx, cancel := context.WithTimeout(ctx, o.config.AgentTimeout)
+			defer cancel()
+
 			systemPrompt := agentCopy.System
 			if strings.Contains(strings.ToLower(agentCopy.Type), "cod") ||
 				strings.Contains(strings.ToLower(agentCopy.Usecase), "cod") {
@@ -371,20 +493,29 @@ func (o *Orchestrator) runAgentsParallel(agents []Agent) []*AgentResult {
 				}
 			}
 
-			resp, err := o.client.GenerateObject(agentCopy.Model, agentCopy.Prompt, systemPrompt, false)
+			resp, attempts, history, err := o.generateObject(agentCtx, agentCopy.Model, agentCopy.Prompt, systemPrompt, false, nil)
+			result.Attempts = attempts
+			result.RetryHistory = history
+			result.RetryErrors = retryHistoryMessages(history)
 
 			result.FinishTime = time.Now()
 			result.Duration = result.FinishTime.Sub(result.StartTime)
 
 			if err != nil {
-				result.Error = err
-				result.ErrorMsg = err.Error()
+				if errors.Is(agentCtx.Err(), context.DeadlineExceeded) || errors.Is(agentCtx.Err(), context.Canceled) {
+					result.Error = agentCtx.Err()
+					result.ErrorMsg = agentCtx.Err().Error()
+				} else {
+					result.Error = err
+					result.ErrorMsg = err.Error()
+				}
 				resultChan <- result
 				return
 			}
 
 			if response, ok := resp["response"].(string); ok {
 				result.Response = response
+				result.TokensConsumed = tokensConsumed(resp)
 			} else {
 				result.Error = fmt.Errorf("invalid response format from agent")
 				result.ErrorMsg = "invalid response format from agent"
@@ -407,7 +538,7 @@ func (o *Orchestrator) runAgentsParallel(agents []Agent) []*AgentResult {
 	return results
 }
 
-func (o *Orchestrator) runAgentsParallelStreaming(agents []Agent, updateChan chan<- *StatusUpdate) []*AgentResult {
+func (o *Orchestrator) runAgentsParallelStreaming(ctx context.Context, agents []Agent, updateChan chan<- *StatusUpdate) []*AgentResult {
 	var wg sync.WaitGroup
 	resultChan := make(chan *AgentResult, len(agents))
 
@@ -420,12 +551,16 @@ func (o *Orchestrator) runAgentsParallelStreaming(agents []Agent, updateChan cha
 			defer wg.Done()
 
 			result := &AgentResult{
+				ID:        agentCopy.ID,
 				Type:      agentCopy.Type,
 				Query:     agentCopy.Prompt,
 				Model:     agentCopy.Model,
 				StartTime: time.Now(),
 			}
 
+			agentCtx, cancel := context.WithTimeout(ctx, o.config.AgentTimeout)
+			defer cancel()
+
 			updateChan <- &StatusUpdate{
 				Type:    "agent_started",
 				Message: fmt.Sprintf("Agent %s started processing", agentCopy.Type),
@@ -453,12 +588,60 @@ func (o *Orchestrator) runAgentsParallelStreaming(agents []Agent, updateChan cha
 				}
 			}
 
-			resp, err := o.client.GenerateObject(agentCopy.Model, agentCopy.Prompt, systemPrompt, false)
+			onToken := func(seq int, token string) {
+				updateChan <- &StatusUpdate{
+					Type:    "agent_token",
+					Message: fmt.Sprintf("Agent %s token", agentCopy.Type),
+					Data: map[string]interface{}{
+						"agent_type": agentCopy.Type,
+						"seq":        seq,
+						"token":      token,
+					},
+					Time: time.Now(),
+				}
+			}
+
+			onRetry := func(attempt int, err error, delay time.Duration) {
+				updateChan <- &StatusUpdate{
+					Type:    "agent_retry",
+					Message: fmt.Sprintf("Agent %s retrying (attempt %d) after error: %v", agentCopy.Type, attempt+1, err),
+					Data: map[string]interface{}{
+						"agent_type": agentCopy.Type,
+						"attempt":    attempt,
+						"error":      err.Error(),
+						"delay_ms":   delay.Milliseconds(),
+					},
+					Time: time.Now(),
+				}
+			}
+
+			resp, attempts, history, err := o.generateObjectStream(agentCtx, agentCopy.Model, agentCopy.Prompt, systemPrompt, false, onToken, onRetry)
+			result.Attempts = attempts
+			result.RetryHistory = history
+			result.RetryErrors = retryHistoryMessages(history)
 
 			result.FinishTime = time.Now()
 			result.Duration = result.FinishTime.Sub(result.StartTime)
 
 			if err != nil {
+				if errors.Is(agentCtx.Err(), context.DeadlineExceeded) || errors.Is(agentCtx.Err(), context.Canceled) {
+					result.Error = agentCtx.Err()
+					result.ErrorMsg = agentCtx.Err().Error()
+
+					updateChan <- &StatusUpdate{
+						Type:    "agent_cancelled",
+						Message: fmt.Sprintf("Agent %s was cancelled: %v", agentCopy.Type, agentCtx.Err()),
+						Data: map[string]interface{}{
+							"agent_type": agentCopy.Type,
+							"reason":     agentCtx.Err().Error(),
+						},
+						Time: time.Now(),
+					}
+
+					resultChan <- result
+					return
+				}
+
 				result.Error = err
 				result.ErrorMsg = err.Error()
 
@@ -476,8 +659,9 @@ func (o *Orchestrator) runAgentsParallelStreaming(agents []Agent, updateChan cha
 				return
 			}
 
-			if response, ok := resp["response"].(string); ok {
+			if response, ok := resp["response"].(string); ok && response != "" {
 				result.Response = response
+				result.TokensConsumed = tokensConsumed(resp)
 			} else {
 				result.Error = fmt.Errorf("invalid response format from agent")
 				result.ErrorMsg = "invalid response format from agent"
@@ -521,31 +705,3 @@ func (o *Orchestrator) runAgentsParallelStreaming(agents []Agent, updateChan cha
 
 	return results
 }
-
-func (o *Orchestrator) buildFinalLearnLMPrompt(originalPrompt string, results []*AgentResult) string {
-	var sb strings.Builder
-
-	sb.WriteString(fmt.Sprintf("ORIGINAL QUERY: %s\n\n", originalPrompt))
-	sb.WriteString("AGENT RESPONSES:\n\n")
-
-	for _, result := range results {
-		if result.Error != nil {
-			continue
-		}
-
-		sb.WriteString(fmt.Sprintf("AGENT: %s\n", result.Type))
-		sb.WriteString(fmt.Sprintf("MODEL: %s\n", result.Model))
-		sb.WriteString(fmt.Sprintf("RESPONSE:\n%s\n\n", result.Response))
-	}
-
-	sb.WriteString(`
-	TASK: Analyze all agent responses provided above and produce a final, fully synthesized, actionable output that directly answers the original query with research evidences. Integrate all relevant information and perspectives from the agents equally—do not favor any single response. 
-
-Your response should not reflect on summary or the inputs—instead, deliver a clear, structured, and technically accurate final result as if you were the final decision-maker. Combine the best ideas, resolve overlaps or conflicts, and generate a unified, high-value deliverable for the user. 
-
-This is not a commentary—this is the final product.
-
-`)
-
-	return sb.String()
-}
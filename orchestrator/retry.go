@@ -0,0 +1,207 @@
+package rao
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryConfig controls how agent calls to the backing model are retried.
+type RetryConfig struct {
+	// MaxAttempts is the total number of tries, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff delay after the first failed attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay regardless of attempt count.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryConfig returns the retry settings used when none are configured.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// isRetryableError reports whether err looks like a transient failure
+// (network hiccup, rate limiting, server error) as opposed to a permanent one
+// (bad request, auth failure, malformed response).
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, transient := range []string{
+		"429", "rate limit", "too many requests",
+		"500", "502", "503", "504", "server error", "service unavailable",
+		"timeout", "temporarily unavailable", "connection reset", "connection refused", "eof",
+	} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoffDelay returns the exponential-with-jitter delay before the given
+// retry attempt (1-indexed: the delay before the 2nd try is backoffDelay(1)).
+func backoffDelay(attempt int, cfg RetryConfig) time.Duration {
+	delay := cfg.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay) + 1))
+	return delay/2 + jitter/2
+}
+
+// retryHistoryMessages converts a RetryHistory into plain strings for JSON output.
+func retryHistoryMessages(history []error) []string {
+	if len(history) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(history))
+	for i, err := range history {
+		msgs[i] = err.Error()
+	}
+	return msgs
+}
+
+// sfResult bundles what a deduplicated, retried GenerateObject call produces
+// so a single singleflight.Do can return it as one interface{} value.
+type sfResult struct {
+	resp     map[string]interface{}
+	attempts int
+	history  []error
+}
+
+// generateObject resolves the backend registered for model, then calls it
+// with retry-with-backoff, and deduplicates identical (model, system,
+// prompt) calls that are in flight at the same time via a keyed
+// singleflight: if several agents in the same level ask the same question
+// concurrently, only one upstream call is made and all callers share its
+// result (and its Attempts/RetryHistory). Results are not cached, so an
+// identical call made after an earlier one finished goes upstream again.
+func (o *Orchestrator) generateObject(ctx context.Context, model, prompt, system string, structured bool, onRetry func(attempt int, err error, delay time.Duration)) (map[string]interface{}, int, []error, error) {
+	backend, err := o.backends.Resolve(model)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	key := model + "\x00" + system + "\x00" + prompt
+
+	v, err, _ := o.callGroup.Do(key, func() (interface{}, error) {
+		resp, attempts, history, err := withRetry(ctx, o.config.Retry, func() (map[string]interface{}, error) {
+			return backend.GenerateObject(ctx, model, prompt, system, structured)
+		}, onRetry)
+
+		return sfResult{resp: resp, attempts: attempts, history: history}, err
+	})
+
+	result, _ := v.(sfResult)
+	return result.resp, result.attempts, result.history, err
+}
+
+// generateObjectStream behaves like generateObject, but streams tokens
+// through onToken as they arrive when the resolved backend supports
+// streaming (falling back to a single GenerateObject call reported as one
+// chunk otherwise). Calls are deduplicated and retried exactly like
+// generateObject: concurrent agents asking an identical (model, system,
+// prompt) question share one upstream call. Only the caller that actually
+// issues the call receives live onToken callbacks - a caller that joins an
+// in-flight call only sees the final response, the same tradeoff dedup
+// already makes for Attempts/RetryHistory.
+func (o *Orchestrator) generateObjectStream(ctx context.Context, model, prompt, system string, structured bool, onToken func(seq int, token string), onRetry func(attempt int, err error, delay time.Duration)) (map[string]interface{}, int, []error, error) {
+	backend, err := o.backends.Resolve(model)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	key := model + "\x00" + system + "\x00" + prompt
+
+	v, err, _ := o.callGroup.Do(key, func() (interface{}, error) {
+		resp, attempts, history, err := withStreamRetry(ctx, o.config.Retry, func() (map[string]interface{}, int, error) {
+			return streamOnceBackend(ctx, backend, model, prompt, system, structured, onToken)
+		}, onRetry)
+
+		return sfResult{resp: resp, attempts: attempts, history: history}, err
+	})
+
+	result, _ := v.(sfResult)
+	return result.resp, result.attempts, result.history, err
+}
+
+// withStreamRetry is like withRetry, but for a streaming call that may have
+// already forwarded tokens to the client before failing: once fn reports any
+// tokens produced, that attempt is committed to even if it errors, since
+// tokens already sent to the client can't be un-sent.
+func withStreamRetry(ctx context.Context, cfg RetryConfig, fn func() (map[string]interface{}, int, error), onRetry func(attempt int, err error, delay time.Duration)) (map[string]interface{}, int, []error, error) {
+	var history []error
+
+	for attempt := 1; ; attempt++ {
+		resp, tokens, err := fn()
+		if err == nil || tokens > 0 || attempt >= cfg.MaxAttempts || !isRetryableError(err) {
+			if err != nil {
+				history = append(history, err)
+			}
+			return resp, attempt, history, err
+		}
+
+		history = append(history, err)
+
+		delay := backoffDelay(attempt, cfg)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return resp, attempt, history, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// withRetry calls fn, retrying on transient errors with exponential backoff
+// and jitter until cfg.MaxAttempts is reached, ctx is done, or fn succeeds.
+// onRetry, if non-nil, is invoked after each failed-but-retryable attempt,
+// before the backoff sleep.
+func withRetry(ctx context.Context, cfg RetryConfig, fn func() (map[string]interface{}, error), onRetry func(attempt int, err error, delay time.Duration)) (map[string]interface{}, int, []error, error) {
+	var history []error
+
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		if err == nil {
+			return resp, attempt, history, nil
+		}
+
+		history = append(history, err)
+
+		if attempt >= cfg.MaxAttempts || !isRetryableError(err) {
+			return nil, attempt, history, err
+		}
+
+		delay := backoffDelay(attempt, cfg)
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt, history, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
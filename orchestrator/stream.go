@@ -0,0 +1,69 @@
+package rao
+
+import (
+	"context"
+	"strings"
+)
+
+// streamToText drains a GenerateObjectStream call into a single string,
+// invoking onChunk (if non-nil) with a 1-indexed sequence number for every
+// chunk as it arrives so callers can forward tokens live while still
+// returning the full response once the stream closes, along with whatever
+// usage metadata the backend reported (nil if it didn't report any).
+func streamToText(ctx context.Context, backend StreamingBackend, model, prompt, system string, structured bool, onChunk func(seq int, chunk string)) (string, map[string]interface{}, error) {
+	chunks, errs, usageCh := backend.GenerateObjectStream(ctx, model, prompt, system, structured)
+
+	var sb strings.Builder
+	seq := 0
+	for chunk := range chunks {
+		seq++
+		sb.WriteString(chunk)
+		if onChunk != nil {
+			onChunk(seq, chunk)
+		}
+	}
+
+	if err, ok := <-errs; ok && err != nil {
+		return sb.String(), nil, err
+	}
+
+	usage := <-usageCh
+	return sb.String(), usage, nil
+}
+
+// streamOnceBackend drains a single attempt at producing an agent's
+// response, forwarding tokens to onToken as they arrive when backend
+// implements StreamingBackend, and otherwise falling back to one
+// GenerateObject call reported as a single chunk. The returned int is the
+// number of tokens produced, which withStreamRetry uses to decide whether a
+// failed attempt already committed output to the client. The returned map
+// carries a "usage" key when the backend reported one, exactly like
+// GenerateObject's response, so tokensConsumed works the same way regardless
+// of which path served the agent.
+func streamOnceBackend(ctx context.Context, backend LLMBackend, model, prompt, system string, structured bool, onToken func(seq int, token string)) (map[string]interface{}, int, error) {
+	if streaming, ok := backend.(StreamingBackend); ok {
+		tokens := 0
+		text, usage, err := streamToText(ctx, streaming, model, prompt, system, structured, func(seq int, token string) {
+			tokens = seq
+			if onToken != nil {
+				onToken(seq, token)
+			}
+		})
+		resp := map[string]interface{}{"response": text}
+		if usage != nil {
+			resp["usage"] = usage
+		}
+		return resp, tokens, err
+	}
+
+	resp, err := backend.GenerateObject(ctx, model, prompt, system, structured)
+	if err != nil {
+		return nil, 0, err
+	}
+	if onToken != nil {
+		if text, ok := resp["response"].(string); ok {
+			onToken(1, text)
+		}
+	}
+	return resp, 1, nil
+}
@@ -0,0 +1,242 @@
+package rao
+
+import (
+	"context"
+	"fmt"
+	gemini "rao/utils"
+	"strings"
+)
+
+// Synthesizer turns the raw results of a fan-out of agents into a single final
+// response. Implementations are free to call back into the backing model as
+// many times as they need (e.g. a critique pass before the final answer).
+type Synthesizer interface {
+	// Synthesize produces the final response for masterPrompt from the given
+	// agent results. Agents that errored are included so implementations can
+	// decide for themselves whether to acknowledge the failure.
+	Synthesize(ctx context.Context, masterPrompt string, results []*AgentResult) (map[string]interface{}, error)
+	// Fallback builds a best-effort final response when Synthesize fails,
+	// without making any further model calls.
+	Fallback(masterPrompt string, results []*AgentResult) map[string]interface{}
+	// Name identifies the strategy, used for ProcessSummary.FinalAgentType.
+	Name() string
+}
+
+// StreamingSynthesizer is implemented by Synthesizers that can report their
+// final output token-by-token as `final_token` StatusUpdates instead of only
+// emitting the completed response. RunAgentsStreaming uses it when available
+// and falls back to Synthesize otherwise.
+type StreamingSynthesizer interface {
+	Synthesizer
+	SynthesizeStream(ctx context.Context, masterPrompt string, results []*AgentResult, onToken func(seq int, token string)) (map[string]interface{}, error)
+}
+
+// NewSynthesizer resolves a Synthesizer by name for the `"synthesis"` HTTP
+// request field. Supported names are "learnlm" (default), "debate", "vote"
+// and "rank".
+func NewSynthesizer(name string, client *gemini.GeminiClient) (Synthesizer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "learnlm":
+		return NewLearnLMSynthesizer(client), nil
+	case "debate":
+		return NewDebateSynthesizer(client), nil
+	case "vote":
+		return NewConsensusSynthesizer(client), nil
+	case "rank":
+		return NewRankSynthesizer(client), nil
+	default:
+		return nil, fmt.Errorf("unknown synthesis strategy: %s", name)
+	}
+}
+
+func fallbackResponse(masterPrompt string, results []*AgentResult) map[string]interface{} {
+	allResponses := make(map[string]interface{})
+	allResponses["type"] = "Combined Agent Responses (Fallback)"
+	allResponses["query"] = masterPrompt
+
+	agentResponsesMap := make(map[string]string)
+	for _, ar := range results {
+		if ar.Error == nil {
+			agentResponsesMap[ar.Type] = ar.Response
+		}
+	}
+
+	allResponses["response"] = "All agent responses provided without merging or filtering (fallback due to synthesis error)."
+	allResponses["agent_responses"] = agentResponsesMap
+
+	return allResponses
+}
+
+func formatAgentResponses(results []*AgentResult) string {
+	var sb strings.Builder
+
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("AGENT: %s\n", result.Type))
+		sb.WriteString(fmt.Sprintf("MODEL: %s\n", result.Model))
+		sb.WriteString(fmt.Sprintf("RESPONSE:\n%s\n\n", result.Response))
+	}
+
+	return sb.String()
+}
+
+// LearnLMSynthesizer is the original strategy: a single unbiased synthesis
+// pass through LearnLM that integrates every agent response equally.
+type LearnLMSynthesizer struct {
+	client *gemini.GeminiClient
+}
+
+func NewLearnLMSynthesizer(client *gemini.GeminiClient) *LearnLMSynthesizer {
+	return &LearnLMSynthesizer{client: client}
+}
+
+func (s *LearnLMSynthesizer) Name() string {
+	return "LearnLM Unbiased Synthesis"
+}
+
+func (s *LearnLMSynthesizer) Synthesize(ctx context.Context, masterPrompt string, results []*AgentResult) (map[string]interface{}, error) {
+	systemPrompt := `You are LearnLM, an unbiased research and synthesis AI. Your task is to analyze all provided agent responses and create a comprehensive, unbiased final output that integrates all perspectives. Do not favor any specific agent or perspective. Present a balanced view that considers all input equally. Focus on factual information and clearly distinguish between consensus views and areas of disagreement. Do not add any personal opinions or biases. Your goal is to provide the most objective and comprehensive synthesis possible.`
+
+	return s.client.GenerateObject(ctx, string(gemini.LearnLM), s.buildPrompt(masterPrompt, results), systemPrompt, false)
+}
+
+func (s *LearnLMSynthesizer) Fallback(masterPrompt string, results []*AgentResult) map[string]interface{} {
+	return fallbackResponse(masterPrompt, results)
+}
+
+// SynthesizeStream is the streaming counterpart to Synthesize: it reports
+// each token via onToken (1-indexed sequence number per call) as LearnLM
+// writes the final synthesis, then returns the assembled response. It
+// satisfies StreamingSynthesizer so RunAgentsStreaming picks it up automatically.
+func (s *LearnLMSynthesizer) SynthesizeStream(ctx context.Context, masterPrompt string, results []*AgentResult, onToken func(seq int, token string)) (map[string]interface{}, error) {
+	systemPrompt := `You are LearnLM, an unbiased research and synthesis AI. Your task is to analyze all provided agent responses and create a comprehensive, unbiased final output that integrates all perspectives. Do not favor any specific agent or perspective. Present a balanced view that considers all input equally. Focus on factual information and clearly distinguish between consensus views and areas of disagreement. Do not add any personal opinions or biases. Your goal is to provide the most objective and comprehensive synthesis possible.`
+
+	response, usage, err := streamToText(ctx, s.client, string(gemini.LearnLM), s.buildPrompt(masterPrompt, results), systemPrompt, false, onToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"response": response}
+	if usage != nil {
+		result["usage"] = usage
+	}
+	return result, nil
+}
+
+func (s *LearnLMSynthesizer) buildPrompt(originalPrompt string, results []*AgentResult) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("ORIGINAL QUERY: %s\n\n", originalPrompt))
+	sb.WriteString("AGENT RESPONSES:\n\n")
+	sb.WriteString(formatAgentResponses(results))
+
+	sb.WriteString(`
+	TASK: Analyze all agent responses provided above and produce a final, fully synthesized, actionable output that directly answers the original query with research evidences. Integrate all relevant information and perspectives from the agents equally—do not favor any single response.
+
+Your response should not reflect on summary or the inputs—instead, deliver a clear, structured, and technically accurate final result as if you were the final decision-maker. Combine the best ideas, resolve overlaps or conflicts, and generate a unified, high-value deliverable for the user.
+
+This is not a commentary—this is the final product.
+
+`)
+
+	return sb.String()
+}
+
+// DebateSynthesizer runs a critique pass over the agent responses before
+// re-synthesizing, so contradictions and weak claims get challenged instead
+// of averaged away.
+type DebateSynthesizer struct {
+	client *gemini.GeminiClient
+}
+
+func NewDebateSynthesizer(client *gemini.GeminiClient) *DebateSynthesizer {
+	return &DebateSynthesizer{client: client}
+}
+
+func (s *DebateSynthesizer) Name() string {
+	return "Debate and Critique Synthesis"
+}
+
+func (s *DebateSynthesizer) Synthesize(ctx context.Context, masterPrompt string, results []*AgentResult) (map[string]interface{}, error) {
+	criticSystemPrompt := `You are a rigorous critic reviewing several independent agent responses to the same query. Identify contradictions between the responses, call out unsupported claims, and note which responses are best supported by evidence. Be specific and concise.`
+
+	criticPrompt := fmt.Sprintf("ORIGINAL QUERY: %s\n\nAGENT RESPONSES:\n\n%s\nTASK: Produce a critique of the responses above, highlighting disagreements and weakly supported claims.", masterPrompt, formatAgentResponses(results))
+
+	critique, err := s.client.GenerateObject(ctx, string(gemini.LearnLM), criticPrompt, criticSystemPrompt, false)
+	if err != nil {
+		return nil, fmt.Errorf("debate critique pass failed: %v", err)
+	}
+
+	critiqueText, _ := critique["response"].(string)
+
+	finalSystemPrompt := `You are LearnLM, synthesizing a final answer after a critique pass. Use the critique to resolve disagreements and discard weakly supported claims, then produce a single confident, well-reasoned answer to the original query.`
+
+	finalPrompt := fmt.Sprintf("ORIGINAL QUERY: %s\n\nAGENT RESPONSES:\n\n%sCRITIQUE:\n%s\n\nTASK: Using the critique to resolve disagreements, produce the final synthesized answer.", masterPrompt, formatAgentResponses(results), critiqueText)
+
+	finalResponse, err := s.client.GenerateObject(ctx, string(gemini.LearnLM), finalPrompt, finalSystemPrompt, false)
+	if err != nil {
+		return nil, err
+	}
+
+	finalResponse["critique"] = critiqueText
+	return finalResponse, nil
+}
+
+func (s *DebateSynthesizer) Fallback(masterPrompt string, results []*AgentResult) map[string]interface{} {
+	return fallbackResponse(masterPrompt, results)
+}
+
+// ConsensusSynthesizer extracts the majority view across agent responses
+// instead of blending every perspective together.
+type ConsensusSynthesizer struct {
+	client *gemini.GeminiClient
+}
+
+func NewConsensusSynthesizer(client *gemini.GeminiClient) *ConsensusSynthesizer {
+	return &ConsensusSynthesizer{client: client}
+}
+
+func (s *ConsensusSynthesizer) Name() string {
+	return "Majority Vote Consensus"
+}
+
+func (s *ConsensusSynthesizer) Synthesize(ctx context.Context, masterPrompt string, results []*AgentResult) (map[string]interface{}, error) {
+	systemPrompt := `You extract the majority consensus across several independent agent responses to the same query. Identify the view held by the most agents and present it as the final answer. Note any significant minority view briefly, but do not let it dilute the majority answer.`
+
+	prompt := fmt.Sprintf("ORIGINAL QUERY: %s\n\nAGENT RESPONSES:\n\n%sTASK: State the majority consensus answer to the original query.", masterPrompt, formatAgentResponses(results))
+
+	return s.client.GenerateObject(ctx, string(gemini.LearnLM), prompt, systemPrompt, false)
+}
+
+func (s *ConsensusSynthesizer) Fallback(masterPrompt string, results []*AgentResult) map[string]interface{} {
+	return fallbackResponse(masterPrompt, results)
+}
+
+// RankSynthesizer scores each agent response on how well it cites evidence
+// and returns the highest-scoring response as the final answer.
+type RankSynthesizer struct {
+	client *gemini.GeminiClient
+}
+
+func NewRankSynthesizer(client *gemini.GeminiClient) *RankSynthesizer {
+	return &RankSynthesizer{client: client}
+}
+
+func (s *RankSynthesizer) Name() string {
+	return "Rank and Pick Synthesis"
+}
+
+func (s *RankSynthesizer) Synthesize(ctx context.Context, masterPrompt string, results []*AgentResult) (map[string]interface{}, error) {
+	systemPrompt := `You rank independent agent responses to the same query by how well they cite concrete evidence. Respond with the full text of the single best-supported response, lightly edited for clarity, and nothing else.`
+
+	prompt := fmt.Sprintf("ORIGINAL QUERY: %s\n\nAGENT RESPONSES:\n\n%sTASK: Pick the response with the strongest cited evidence and return it as the final answer.", masterPrompt, formatAgentResponses(results))
+
+	return s.client.GenerateObject(ctx, string(gemini.LearnLM), prompt, systemPrompt, false)
+}
+
+func (s *RankSynthesizer) Fallback(masterPrompt string, results []*AgentResult) map[string]interface{} {
+	return fallbackResponse(masterPrompt, results)
+}
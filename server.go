@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	rao "rao/orchestrator"
 	gemini "rao/utils"
-	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,53 +17,22 @@ import (
 
 var system string
 
-type SessionManager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-}
-
-type Session struct {
-	ID       string
-	UpdateCh chan *rao.StatusUpdate
-	Done     chan struct{}
-}
+// sessionTTL bounds how long a session's buffered updates are kept for a
+// client to resume from. The in-memory store prunes updates older than this
+// while the session is open and drops the buffer when the session closes;
+// the Redis store expires a session's stream this long after it was last
+// touched.
+const sessionTTL = 10 * time.Minute
 
-var sessionManager = SessionManager{
-	sessions: make(map[string]*Session),
-}
-
-func (sm *SessionManager) CreateSession(id string) *Session {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// resumeProbeTimeout bounds how long the WebSocket handler waits for an
+// optional {"resume_from": N} message before falling back to resumeFrom=0 -
+// a plain "connect and watch" client, the common case, never sends one and
+// would otherwise block the handshake forever.
+const resumeProbeTimeout = 200 * time.Millisecond
 
-	session := &Session{
-		ID:       id,
-		UpdateCh: make(chan *rao.StatusUpdate, 100),
-		Done:     make(chan struct{}),
-	}
-
-	sm.sessions[id] = session
-	return session
-}
-
-func (sm *SessionManager) GetSession(id string) (*Session, bool) {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	session, exists := sm.sessions[id]
-	return session, exists
-}
-
-func (sm *SessionManager) CloseSession(id string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	if session, exists := sm.sessions[id]; exists {
-		close(session.UpdateCh)
-		close(session.Done)
-		delete(sm.sessions, id)
-	}
-}
+// sessionStore is the process-wide SessionStore. It defaults to an
+// in-memory buffer; set REDIS_ADDR to share sessions across replicas.
+var sessionStore = NewSessionStore(os.Getenv("REDIS_ADDR"), sessionTTL)
 
 func init() {
 	content, err := ioutil.ReadFile("prompts/system.txt")
@@ -114,7 +83,7 @@ func main() {
 				"error": "Invalid prompt format",
 			})
 		}
-		resp, err := client.GenerateObject(string(gemini.Gemini_2_5_Pro), prompt, system, true)
+		resp, err := client.GenerateObject(c.Context(), string(gemini.Gemini_2_5_Pro), prompt, system, true)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err,
@@ -143,14 +112,16 @@ func main() {
 			modelType = model
 		}
 
-		orchestrator, err := rao.NewOrchestrator()
+		synthesis, _ := requestBody["synthesis"].(string)
+
+		orchestrator, err := rao.NewOrchestratorWithSynthesis(synthesis)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create orchestrator: " + err.Error(),
 			})
 		}
 
-		orchestrationResult, err := orchestrator.RunAgents(prompt, system, modelType)
+		orchestrationResult, err := orchestrator.RunAgents(c.Context(), prompt, system, modelType)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to run agents: " + err.Error(),
@@ -165,24 +136,48 @@ func main() {
 		sessionID := c.Params("sessionID")
 		log.Printf("WebSocket connection established for session: %s", sessionID)
 
-		session, exists := sessionManager.GetSession(sessionID)
-		if !exists {
+		if !sessionStore.Exists(sessionID) {
+			c.WriteJSON(fiber.Map{
+				"type":    "error",
+				"message": "Invalid session ID or session expired",
+			})
+			return
+		}
+
+		// The client may send {"resume_from": N} as its first message to
+		// replay everything published after sequence N instead of starting
+		// from scratch; anything else (or nothing within resumeProbeTimeout)
+		// resumes from the beginning. The deadline is cleared afterwards so
+		// it doesn't bound the long-lived read loop below.
+		var resumeFrom uint64
+		var firstMsg map[string]interface{}
+		c.SetReadDeadline(time.Now().Add(resumeProbeTimeout))
+		if err := c.ReadJSON(&firstMsg); err == nil {
+			if v, ok := firstMsg["resume_from"].(float64); ok && v > 0 {
+				resumeFrom = uint64(v)
+			}
+		}
+		c.SetReadDeadline(time.Time{})
 
+		updates, unsubscribe, err := sessionStore.Subscribe(sessionID, resumeFrom)
+		if err != nil {
 			c.WriteJSON(fiber.Map{
 				"type":    "error",
 				"message": "Invalid session ID or session expired",
 			})
 			return
 		}
+		defer unsubscribe()
 
+		// A disconnect here only drops this live subscription; the
+		// orchestration keeps running and its updates stay buffered so a
+		// reconnect can resume with {"resume_from": N}.
 		go func() {
-			defer sessionManager.CloseSession(sessionID)
-
 			for {
 				var msg map[string]interface{}
 				if err := c.ReadJSON(&msg); err != nil {
-
 					log.Printf("WebSocket connection closed for session %s: %v", sessionID, err)
+					unsubscribe()
 					return
 				}
 
@@ -190,19 +185,9 @@ func main() {
 			}
 		}()
 
-		for {
-			select {
-			case update, open := <-session.UpdateCh:
-				if !open {
-
-					return
-				}
-				if err := c.WriteJSON(update); err != nil {
-					log.Printf("Error sending update: %v", err)
-					return
-				}
-			case <-session.Done:
-
+		for buffered := range updates {
+			if err := c.WriteJSON(buffered); err != nil {
+				log.Printf("Error sending update: %v", err)
 				return
 			}
 		}
@@ -228,15 +213,37 @@ func main() {
 			modelType = model
 		}
 
+		synthesis, _ := requestBody["synthesis"].(string)
+
 		sessionID := generateSessionID()
-		session := sessionManager.CreateSession(sessionID)
+		ctx := sessionStore.CreateSession(sessionID)
+
+		updateCh := make(chan *rao.StatusUpdate, 100)
+		relayDone := make(chan struct{})
+
+		// Relay every update into the session store as it's produced, so it's
+		// buffered for replay and fanned out to whichever replica a client's
+		// WebSocket happens to be connected to. The session is only closed
+		// once this has drained updateCh, so no published update is lost.
+		go func() {
+			defer close(relayDone)
+			for update := range updateCh {
+				if _, err := sessionStore.Publish(sessionID, update); err != nil {
+					log.Printf("Error publishing update for session %s: %v", sessionID, err)
+				}
+			}
+		}()
 
 		go func() {
-			defer sessionManager.CloseSession(sessionID)
+			defer func() {
+				close(updateCh)
+				<-relayDone
+				sessionStore.CloseSession(sessionID)
+			}()
 
-			orchestrator, err := rao.NewOrchestrator()
+			orchestrator, err := rao.NewOrchestratorWithSynthesis(synthesis)
 			if err != nil {
-				session.UpdateCh <- &rao.StatusUpdate{
+				updateCh <- &rao.StatusUpdate{
 					Type:    "error",
 					Message: "Failed to create orchestrator: " + err.Error(),
 					Time:    time.Now(),
@@ -244,7 +251,7 @@ func main() {
 				return
 			}
 
-			result, err := orchestrator.RunAgentsStreaming(prompt, system, modelType, session.UpdateCh)
+			result, err := orchestrator.RunAgentsStreaming(ctx, prompt, system, modelType, updateCh)
 			if err != nil {
 
 				log.Printf("Error running streaming orchestration: %v", err)
@@ -257,14 +264,12 @@ func main() {
 					finalResultStr = finalResultStr[:1000] + "... (truncated)"
 				}
 
-				session.UpdateCh <- &rao.StatusUpdate{
+				updateCh <- &rao.StatusUpdate{
 					Type:    "final_result",
 					Message: "Final orchestration result",
 					Data:    result,
 					Time:    time.Now(),
 				}
-
-				close(session.Done)
 			}
 		}()
 
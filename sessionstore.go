@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	rao "rao/orchestrator"
+	"sync"
+	"time"
+)
+
+// BufferedUpdate pairs a StatusUpdate with the monotonically increasing
+// sequence number it was published under within its session, so a client
+// can ask to resume from a given point instead of replaying everything.
+type BufferedUpdate struct {
+	Seq    uint64            `json:"seq"`
+	Update *rao.StatusUpdate `json:"update"`
+}
+
+// SessionStore tracks in-flight orchestrations and buffers their emitted
+// StatusUpdates so a WebSocket client that disconnects and reconnects to
+// /ws/agents/:sessionID can resume from a sequence number instead of losing
+// everything it missed. Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// CreateSession registers a new session and returns a context that is
+	// cancelled when the session is closed. A session is closed by its
+	// orchestration completing (or its own OrchestrationTimeout elapsing),
+	// not by a client's WebSocket disconnecting - a disconnect only drops
+	// that subscriber so it can reconnect and resume from {"resume_from": N}.
+	CreateSession(id string) context.Context
+	// CloseSession cancels the session's context and ends the session. The
+	// in-memory store drops the session's buffer at once; the Redis store
+	// leaves its stream in place until the TTL expires.
+	CloseSession(id string)
+	// Exists reports whether id refers to a known session. For the
+	// in-memory store that means created and not yet closed; the Redis
+	// store also reports a closed session until its stream expires.
+	Exists(id string) bool
+	// Publish buffers update under the next sequence number for id and
+	// forwards it to any live subscribers.
+	Publish(id string, update *rao.StatusUpdate) (uint64, error)
+	// Subscribe returns a channel of updates for id: anything already
+	// buffered with Seq > resumeFrom is replayed first, followed by live
+	// updates as they're published. The returned func unsubscribes and must
+	// be called once the caller is done reading.
+	Subscribe(id string, resumeFrom uint64) (<-chan BufferedUpdate, func(), error)
+}
+
+// NewSessionStore returns a Redis-backed SessionStore when redisAddr is
+// non-empty (so updates and resumable subscriptions work across replicas of
+// the Fiber server), or an in-memory one otherwise.
+func NewSessionStore(redisAddr string, ttl time.Duration) SessionStore {
+	if redisAddr != "" {
+		return NewRedisSessionStore(redisAddr, ttl)
+	}
+	return NewInMemorySessionStore(ttl)
+}
+
+type sessionEntry struct {
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	seq    uint64
+	buffer []BufferedUpdate
+	subs   map[chan BufferedUpdate]struct{}
+	closed bool
+}
+
+func (e *sessionEntry) pruneLocked(ttl time.Duration) {
+	if ttl <= 0 || len(e.buffer) == 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-ttl)
+	i := 0
+	for ; i < len(e.buffer); i++ {
+		if e.buffer[i].Update.Time.After(cutoff) {
+			break
+		}
+	}
+	if i > 0 {
+		e.buffer = e.buffer[i:]
+	}
+}
+
+// InMemorySessionStore is the default SessionStore: it keeps every
+// session's update buffer and subscriber list in process memory, pruning
+// buffered updates older than ttl.
+type InMemorySessionStore struct {
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	sessions map[string]*sessionEntry
+}
+
+func NewInMemorySessionStore(ttl time.Duration) *InMemorySessionStore {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &InMemorySessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*sessionEntry),
+	}
+}
+
+func (s *InMemorySessionStore) CreateSession(id string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	s.sessions[id] = &sessionEntry{
+		cancel: cancel,
+		subs:   make(map[chan BufferedUpdate]struct{}),
+	}
+	s.mu.Unlock()
+
+	return ctx
+}
+
+func (s *InMemorySessionStore) Exists(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.sessions[id]
+	return ok
+}
+
+func (s *InMemorySessionStore) CloseSession(id string) {
+	s.mu.Lock()
+	entry, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	entry.cancel()
+
+	entry.mu.Lock()
+	entry.closed = true
+	for ch := range entry.subs {
+		close(ch)
+	}
+	entry.subs = nil
+	entry.mu.Unlock()
+}
+
+func (s *InMemorySessionStore) Publish(id string, update *rao.StatusUpdate) (uint64, error) {
+	s.mu.RLock()
+	entry, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("session %s not found", id)
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	entry.seq++
+	seq := entry.seq
+	entry.buffer = append(entry.buffer, BufferedUpdate{Seq: seq, Update: update})
+	entry.pruneLocked(s.ttl)
+
+	for ch := range entry.subs {
+		select {
+		case ch <- BufferedUpdate{Seq: seq, Update: update}:
+		default:
+			// A slow subscriber shouldn't block publishing to the others;
+			// this update is dropped for it, leaving a gap in the Seq
+			// values it receives. The update stays buffered, so a
+			// reconnect with resume_from can replay it.
+		}
+	}
+
+	return seq, nil
+}
+
+func (s *InMemorySessionStore) Subscribe(id string, resumeFrom uint64) (<-chan BufferedUpdate, func(), error) {
+	s.mu.RLock()
+	entry, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("session %s not found", id)
+	}
+
+	entry.mu.Lock()
+	if entry.closed {
+		entry.mu.Unlock()
+		ch := make(chan BufferedUpdate)
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	var replay []BufferedUpdate
+	for _, buffered := range entry.buffer {
+		if buffered.Seq > resumeFrom {
+			replay = append(replay, buffered)
+		}
+	}
+
+	// Sized to hold the whole replay backlog plus live-update slack so the
+	// send loop below never blocks while still holding entry.mu - a full
+	// channel here would also wedge every future Publish/CloseSession for
+	// this session, since they take the same lock.
+	ch := make(chan BufferedUpdate, len(replay)+100)
+	for _, buffered := range replay {
+		ch <- buffered
+	}
+	entry.subs[ch] = struct{}{}
+	entry.mu.Unlock()
+
+	unsubscribe := func() {
+		entry.mu.Lock()
+		if !entry.closed {
+			delete(entry.subs, ch)
+		}
+		entry.mu.Unlock()
+	}
+
+	return ch, unsubscribe, nil
+}
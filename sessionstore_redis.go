@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	rao "rao/orchestrator"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSessionStore is the optional SessionStore backend: it keeps each
+// session's buffered updates in a Redis Stream so any replica of this
+// server can publish to or resume a session, not just the one that started
+// it. Stream entry IDs double as the sequence number a client resumes from.
+type RedisSessionStore struct {
+	rdb *redis.Client
+	ttl time.Duration
+
+	cancels sync.Map // session id -> context.CancelFunc
+}
+
+func NewRedisSessionStore(addr string, ttl time.Duration) *RedisSessionStore {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &RedisSessionStore{
+		rdb: redis.NewClient(&redis.Options{Addr: addr}),
+		ttl: ttl,
+	}
+}
+
+func (s *RedisSessionStore) streamKey(id string) string {
+	return "rao:session:" + id
+}
+
+func (s *RedisSessionStore) CreateSession(id string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancels.Store(id, cancel)
+
+	// Seed the stream so Exists can distinguish "created, no updates yet"
+	// from "never created", and set its TTL so abandoned sessions expire.
+	s.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.streamKey(id),
+		Values: map[string]interface{}{"type": "session_created"},
+	})
+	s.rdb.Expire(ctx, s.streamKey(id), s.ttl)
+
+	return ctx
+}
+
+func (s *RedisSessionStore) CloseSession(id string) {
+	if cancel, ok := s.cancels.LoadAndDelete(id); ok {
+		cancel.(context.CancelFunc)()
+	}
+	s.rdb.Expire(context.Background(), s.streamKey(id), s.ttl)
+}
+
+func (s *RedisSessionStore) Exists(id string) bool {
+	n, err := s.rdb.Exists(context.Background(), s.streamKey(id)).Result()
+	return err == nil && n > 0
+}
+
+func (s *RedisSessionStore) Publish(id string, update *rao.StatusUpdate) (uint64, error) {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	streamID, err := s.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.streamKey(id),
+		Values: map[string]interface{}{"update": payload},
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	s.rdb.Expire(ctx, s.streamKey(id), s.ttl)
+
+	return streamSeq(streamID), nil
+}
+
+func (s *RedisSessionStore) Subscribe(id string, resumeFrom uint64) (<-chan BufferedUpdate, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan BufferedUpdate, 100)
+
+	go func() {
+		defer close(ch)
+
+		lastID := seqStreamID(resumeFrom)
+		for {
+			streams, err := s.rdb.XRead(ctx, &redis.XReadArgs{
+				Streams: []string{s.streamKey(id), lastID},
+				Block:   5 * time.Second,
+				Count:   100,
+			}).Result()
+			if err != nil {
+				if err == redis.Nil || ctx.Err() != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					continue
+				}
+				return
+			}
+
+			for _, stream := range streams {
+				for _, entry := range stream.Messages {
+					raw, _ := entry.Values["update"].(string)
+					if raw == "" {
+						lastID = entry.ID
+						continue
+					}
+
+					var update rao.StatusUpdate
+					if jsonErr := json.Unmarshal([]byte(raw), &update); jsonErr != nil {
+						lastID = entry.ID
+						continue
+					}
+
+					seq := streamSeq(entry.ID)
+					select {
+					case ch <- BufferedUpdate{Seq: seq, Update: &update}:
+					case <-ctx.Done():
+						return
+					}
+					lastID = entry.ID
+				}
+			}
+		}
+	}()
+
+	return ch, cancel, nil
+}
+
+// streamSeq turns a Redis Stream entry ID ("<ms>-<seq>") into the monotonic
+// uint64 sequence number exposed to clients.
+func streamSeq(streamID string) uint64 {
+	for i := len(streamID) - 1; i >= 0; i-- {
+		if streamID[i] == '-' {
+			ms, _ := strconv.ParseUint(streamID[:i], 10, 64)
+			seq, _ := strconv.ParseUint(streamID[i+1:], 10, 64)
+			return ms*1000 + seq
+		}
+	}
+	n, _ := strconv.ParseUint(streamID, 10, 64)
+	return n
+}
+
+// seqStreamID converts a client-supplied resume_from sequence back into a
+// Redis Stream ID to pass as the XREAD starting point.
+func seqStreamID(seq uint64) string {
+	if seq == 0 {
+		return "0"
+	}
+	return strconv.FormatUint(seq/1000, 10) + "-" + strconv.FormatUint(seq%1000, 10)
+}